@@ -14,6 +14,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,6 +25,8 @@ type Cache interface {
 	Set(*http.Request, *http.Response) error
 }
 
+// Transport bypasses Cache.Get (but still calls Cache.Set) for requests with
+// Cache-Control no-cache / max-age=0 or Pragma no-cache.
 type Transport struct {
 	Transport   http.RoundTripper
 	RetryCount  int
@@ -32,7 +36,16 @@ type Transport struct {
 	OnReq       func(*http.Request)
 }
 
-type FileCache struct{ Root string }
+// FileCache stores responses as files in Root.
+// Entries older than MaxAge (if > 0) are treated as misses. With UseCacheHeaders set,
+// the Cache-Control max-age / no-cache / no-store and Expires headers of the cached
+// response take precedence over MaxAge - max-age is measured from the time of Set plus
+// the Age header of the response; no-store responses are not stored at all.
+type FileCache struct {
+	Root            string
+	MaxAge          time.Duration
+	UseCacheHeaders bool
+}
 
 var invalidFileNameChars = regexp.MustCompile(`[^-_0-9a-zA-Z]+`)
 
@@ -48,7 +61,7 @@ func (t Transport) Client() (*http.Client, error) {
 }
 
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if t.Cache != nil {
+	if t.Cache != nil && !isForceRefresh(req) {
 		if res, err := t.Cache.Get(req); res != nil || (err != nil && !os.IsNotExist(err)) {
 			return res, err
 		}
@@ -94,7 +107,16 @@ func (c *FileCache) Key(req *http.Request) string {
 func (c *FileCache) Init() error { return os.MkdirAll(c.Root, os.ModePerm) }
 
 func (c *FileCache) Get(req *http.Request) (*http.Response, error) {
-	bs, err := ioutil.ReadFile(c.Key(req))
+	f, err := os.Open(c.Key(req))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	bs, err := ioutil.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
@@ -103,10 +125,37 @@ func (c *FileCache) Get(req *http.Request) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.isExpired(res, info.ModTime()) {
+		res.Body.Close()
+		return nil, os.ErrNotExist
+	}
 	return res, nil
 }
 
+func (c *FileCache) isExpired(res *http.Response, storedAt time.Time) bool {
+	if c.UseCacheHeaders {
+		if hasCacheDirective(res.Header, "no-cache") || hasCacheDirective(res.Header, "no-store") {
+			return true
+		}
+		if maxAge, ok := cacheMaxAge(res.Header); ok {
+			age := time.Since(storedAt)
+			if n, err := strconv.Atoi(res.Header.Get("Age")); err == nil {
+				age += time.Duration(n) * time.Second
+			}
+			return age > maxAge
+		}
+		if expires := res.Header.Get("Expires"); expires != "" {
+			t, err := http.ParseTime(expires)
+			return err != nil || time.Now().After(t)
+		}
+	}
+	return c.MaxAge > 0 && time.Since(storedAt) > c.MaxAge
+}
+
 func (c *FileCache) Set(req *http.Request, res *http.Response) error {
+	if c.UseCacheHeaders && hasCacheDirective(res.Header, "no-store") {
+		return nil
+	}
 	bs, err := httputil.DumpResponse(res, true)
 	if err != nil {
 		return err
@@ -118,3 +167,41 @@ func (c *FileCache) Set(req *http.Request, res *http.Response) error {
 	bs = append([]byte(u+"\n"), bs...)
 	return ioutil.WriteFile(c.Key(req), bs, os.ModePerm)
 }
+
+func isForceRefresh(req *http.Request) bool {
+	if hasCacheDirective(req.Header, "no-cache") || strings.EqualFold(req.Header.Get("Pragma"), "no-cache") {
+		return true
+	}
+	maxAge, ok := cacheMaxAge(req.Header)
+	return ok && maxAge == 0
+}
+
+func cacheDirectives(h http.Header) []string {
+	ds := []string{}
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			ds = append(ds, strings.ToLower(strings.TrimSpace(d)))
+		}
+	}
+	return ds
+}
+
+func hasCacheDirective(h http.Header, directive string) bool {
+	for _, d := range cacheDirectives(h) {
+		if d == directive {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheMaxAge(h http.Header) (time.Duration, bool) {
+	for _, d := range cacheDirectives(h) {
+		if strings.HasPrefix(d, "max-age=") {
+			if n, err := strconv.Atoi(strings.Trim(d[len("max-age="):], `"`)); err == nil {
+				return time.Duration(n) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
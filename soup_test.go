@@ -1,8 +1,14 @@
 package soup
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSoup(t *testing.T) {
@@ -12,3 +18,120 @@ func TestSoup(t *testing.T) {
 	}
 
 }
+
+func TestFileCacheMaxAge(t *testing.T) {
+	c := &FileCache{Root: t.TempDir(), MaxAge: time.Hour}
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	res := &http.Response{StatusCode: 200, ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{}, Body: http.NoBody}
+	if err := c.Set(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := c.Get(req); err != nil || res == nil {
+		t.Errorf("Got %v %v, expected fresh cache hit", res, err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(c.Key(req), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(req); !os.IsNotExist(err) {
+		t.Errorf("Got %v, expected expired cache miss", err)
+	}
+}
+
+func TestFileCacheHeaders(t *testing.T) {
+	future, past := time.Now().Add(time.Hour).UTC(), time.Now().Add(-time.Hour).UTC()
+	cases := []struct {
+		name   string
+		header http.Header
+		maxAge time.Duration
+		age    time.Duration
+		hit    bool
+	}{
+		{"max-age fresh", http.Header{"Cache-Control": {"max-age=60"}}, 0, 0, true},
+		{"max-age stale", http.Header{"Cache-Control": {"max-age=60"}}, 0, 2 * time.Minute, false},
+		{"max-age with Age header", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"90"}}, 0, 0, false},
+		{"max-age overrides smaller MaxAge", http.Header{"Cache-Control": {"max-age=60"}}, time.Second, 30 * time.Second, true},
+		{"max-age overrides larger MaxAge", http.Header{"Cache-Control": {"public, max-age=60"}}, time.Hour, 2 * time.Minute, false},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, 0, 0, false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache"}}, 0, 0, false},
+		{"future Expires", http.Header{"Expires": {future.Format(http.TimeFormat)}}, 0, 0, true},
+		{"past Expires", http.Header{"Expires": {past.Format(http.TimeFormat)}}, 0, 0, false},
+		{"invalid Expires", http.Header{"Expires": {"0"}}, 0, 0, false},
+	}
+	for _, tc := range cases {
+		c := &FileCache{Root: t.TempDir(), MaxAge: tc.maxAge, UseCacheHeaders: true}
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		res := &http.Response{StatusCode: 200, ProtoMajor: 1, ProtoMinor: 1, Header: tc.header, Body: http.NoBody}
+		if err := c.Set(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if tc.age != 0 {
+			mtime := time.Now().Add(-tc.age)
+			if err := os.Chtimes(c.Key(req), mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := c.Get(req)
+		if hit := err == nil && res != nil; hit != tc.hit {
+			t.Errorf("%s: Got hit=%v (%v), expected hit=%v", tc.name, hit, err, tc.hit)
+		}
+	}
+
+	c := &FileCache{Root: t.TempDir(), UseCacheHeaders: true}
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	res := &http.Response{StatusCode: 200, ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"Cache-Control": {"no-store"}}, Body: http.NoBody}
+	if err := c.Set(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Key(req)); !os.IsNotExist(err) {
+		t.Errorf("Got %v, expected no-store response not to be written", err)
+	}
+}
+
+func TestTransportForceRefresh(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, "%d", requests)
+	}))
+	defer s.Close()
+	get := func(c *http.Client, header http.Header) string {
+		req, _ := http.NewRequest("GET", s.URL, nil)
+		for k, vs := range header {
+			req.Header[k] = vs
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		bs, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+	for _, header := range []http.Header{
+		{"Cache-Control": {"no-cache"}},
+		{"Cache-Control": {"max-age=0"}},
+		{"Pragma": {"no-cache"}},
+	} {
+		requests = 0
+		c, err := Transport{Cache: &FileCache{Root: t.TempDir()}}.Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := get(c, nil); body != "1" {
+			t.Errorf("%v: Got %s, expected 1 from upstream", header, body)
+		}
+		if body := get(c, nil); body != "1" || requests != 1 {
+			t.Errorf("%v: Got %s (%d requests), expected 1 from cache", header, body, requests)
+		}
+		if body := get(c, header); body != "2" || requests != 2 {
+			t.Errorf("%v: Got %s (%d requests), expected 2 from upstream", header, body, requests)
+		}
+		if body := get(c, nil); body != "2" || requests != 2 {
+			t.Errorf("%v: Got %s (%d requests), expected refreshed 2 from cache", header, body, requests)
+		}
+	}
+}